@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"kubetuunel/internal"
+)
+
+// startTunnelRequest is the JSON body accepted by POST /tunnels.
+type startTunnelRequest struct {
+	Site    string `json:"site"`
+	Service string `json:"service"`
+}
+
+// newAdminServer builds the local admin HTTP/JSON API backed by manager. It is only
+// started when --admin-addr is set; see run() in root.go.
+func newAdminServer(addr string, manager *internal.TunnelManager) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/tunnels", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleListTunnels(w, r, manager)
+		case http.MethodPost:
+			handleStartTunnel(w, r, manager)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/tunnels/", func(w http.ResponseWriter, r *http.Request) {
+		id, action := parseTunnelPath(r.URL.Path)
+		switch {
+		case r.Method == http.MethodDelete && action == "":
+			handleStopTunnel(w, r, manager, id)
+		case r.Method == http.MethodPost && action == "restart":
+			handleRestartTunnel(w, r, manager, id)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// parseTunnelPath splits "/tunnels/{id}" or "/tunnels/{id}/restart" into the tunnel
+// ID and an optional trailing action.
+func parseTunnelPath(path string) (id internal.TunnelID, action string) {
+	trimmed := strings.TrimPrefix(path, "/tunnels/")
+	parts := strings.SplitN(trimmed, "/", 2)
+	id = internal.TunnelID(parts[0])
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	return id, action
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func handleListTunnels(w http.ResponseWriter, r *http.Request, manager *internal.TunnelManager) {
+	writeJSON(w, http.StatusOK, manager.List())
+}
+
+func handleStartTunnel(w http.ResponseWriter, r *http.Request, manager *internal.TunnelManager) {
+	var req startTunnelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Site == "" || req.Service == "" {
+		http.Error(w, "site and service are required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := manager.Start(req.Site, req.Service)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]internal.TunnelID{"id": id})
+}
+
+func handleStopTunnel(w http.ResponseWriter, r *http.Request, manager *internal.TunnelManager, id internal.TunnelID) {
+	if err := manager.Stop(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRestartTunnel(w http.ResponseWriter, r *http.Request, manager *internal.TunnelManager, id internal.TunnelID) {
+	if err := manager.Restart(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to encode admin API response", "error", err)
+	}
+}
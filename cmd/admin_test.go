@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kubetuunel/internal"
+)
+
+func TestHandleHealthz(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+
+	handleHealthz(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("handleHealthz status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Fatalf("handleHealthz body = %q, want it to contain status ok", rec.Body.String())
+	}
+}
+
+func TestHandleListTunnelsEmpty(t *testing.T) {
+	manager := internal.NewTunnelManager(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/tunnels", nil)
+	rec := httptest.NewRecorder()
+
+	handleListTunnels(rec, req, manager)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("handleListTunnels status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != "[]" {
+		t.Fatalf("handleListTunnels body = %q, want an empty JSON array", got)
+	}
+}
+
+func TestHandleStartTunnelValidation(t *testing.T) {
+	manager := internal.NewTunnelManager(context.Background())
+
+	req := httptest.NewRequest(http.MethodPost, "/tunnels", strings.NewReader(`{"site":""}`))
+	rec := httptest.NewRecorder()
+
+	handleStartTunnel(rec, req, manager)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("handleStartTunnel with missing service status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleStopAndRestartUnknownID(t *testing.T) {
+	manager := internal.NewTunnelManager(context.Background())
+
+	rec := httptest.NewRecorder()
+	handleStopTunnel(rec, httptest.NewRequest(http.MethodDelete, "/tunnels/does-not-exist", nil), manager, internal.TunnelID("does-not-exist"))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("handleStopTunnel for unknown id status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	handleRestartTunnel(rec, httptest.NewRequest(http.MethodPost, "/tunnels/does-not-exist/restart", nil), manager, internal.TunnelID("does-not-exist"))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("handleRestartTunnel for unknown id status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestParseTunnelPath(t *testing.T) {
+	cases := []struct {
+		path       string
+		wantID     internal.TunnelID
+		wantAction string
+	}{
+		{path: "/tunnels/t-1", wantID: "t-1", wantAction: ""},
+		{path: "/tunnels/t-1/restart", wantID: "t-1", wantAction: "restart"},
+	}
+
+	for _, c := range cases {
+		id, action := parseTunnelPath(c.path)
+		if id != c.wantID || action != c.wantAction {
+			t.Errorf("parseTunnelPath(%q) = (%q, %q), want (%q, %q)", c.path, id, action, c.wantID, c.wantAction)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"sort"
 	"syscall" // Required for syscall.SIGTERM
+	"time"
 
 	"kubetuunel/internal"
 
@@ -14,6 +17,8 @@ import (
 )
 
 var configPath string
+var adminAddr string
+var metricsAddr string
 
 // run is the main logic for the Cobra command.
 func run(cmd *cobra.Command, args []string) {
@@ -24,23 +29,24 @@ func run(cmd *cobra.Command, args []string) {
 			sitesToUse = append(sitesToUse, name)
 		}
 		if len(sitesToUse) == 0 {
-			log.Println("ℹ️ No sites specified and no sites found in the configuration to process.")
+			slog.Info("no sites specified and no sites found in the configuration to process")
 			return
 		}
 	}
 
-	var wg sync.WaitGroup
-	// allActiveStopFuncs will store all the stop functions for successfully established tunnels.
-	var allActiveStopFuncs []func()
-	var mu sync.Mutex // Mutex to protect concurrent appends to allActiveStopFuncs
+	slog.Info("attempting to forward services for sites", "sites", sitesToUse)
 
-	log.Printf("🔍 Attempting to forward services for sites: %v", sitesToUse)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := internal.NewTunnelManager(ctx)
+	startedAny := false
 
 	for _, siteName := range sitesToUse {
 		// Ensure site configuration exists.
 		site, ok := internal.Cfg.Sites[siteName]
 		if !ok {
-			log.Printf("⚠️ Site '%s' not found in config, skipping...", siteName)
+			slog.Warn("site not found in config, skipping", "site", siteName)
 			continue
 		}
 
@@ -50,87 +56,113 @@ func run(cmd *cobra.Command, args []string) {
 		actualServices := servicesToUse
 		if len(actualServices) == 0 { // No global services filter, so use all from this site
 			if site.Services == nil {
-				log.Printf("ℹ️ Site '%s' has no services defined, skipping...", siteName)
+				slog.Info("site has no services defined, skipping", "site", siteName)
 				continue
 			}
 			for name := range site.Services {
 				actualServices = append(actualServices, name)
 			}
 			if len(actualServices) == 0 {
-				log.Printf("ℹ️ No services to forward for site '%s'.", siteName)
+				slog.Info("no services to forward for site", "site", siteName)
 				continue
 			}
 		}
 
-		wg.Add(1)
-		go func(currentSiteName string, servicesToForward []string) {
-			defer wg.Done()
-			log.Printf("🚀 Starting forwarding for site: %s, services: %v", currentSiteName, servicesToForward)
+		for _, serviceName := range actualServices {
+			if _, err := manager.Start(siteName, serviceName); err != nil {
+				slog.Error("failed to start tunnel", "site", siteName, "service", serviceName, "error", err)
+				continue
+			}
+			startedAny = true
+		}
+	}
 
-			// Call the updated internal.Forward function
-			stopFuncsForSite, err := internal.Forward(currentSiteName, servicesToForward...)
+	if !startedAny {
+		slog.Warn("no tunnels were successfully started, exiting")
+		return
+	}
 
-			// Lock before modifying shared slice
-			mu.Lock()
-			if len(stopFuncsForSite) > 0 {
-				allActiveStopFuncs = append(allActiveStopFuncs, stopFuncsForSite...)
-				log.Printf("✅ Successfully established %d tunnel(s) for site %s.", len(stopFuncsForSite), currentSiteName)
+	var adminServer *http.Server
+	if adminAddr != "" {
+		adminServer = newAdminServer(adminAddr, manager)
+		go func() {
+			slog.Info("admin API listening", "addr", adminAddr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("admin API server error", "error", err)
 			}
-			mu.Unlock()
+		}()
+	}
 
-			if err != nil {
-				// The error from internal.Forward might indicate partial success.
-				// stopFuncsForSite could still have functions for tunnels that did start.
-				log.Printf("❌ Error during forwarding for site %s: %v", currentSiteName, err)
+	var metricsServer *http.Server
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", internal.MetricsHandler())
+		metricsServer = &http.Server{Addr: metricsAddr, Handler: mux}
+		go func() {
+			slog.Info("metrics endpoint listening", "addr", metricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("metrics server error", "error", err)
 			}
-
-		}(siteName, actualServices)
+		}()
 	}
 
-	wg.Wait()
+	slog.Info("tunnels started and supervised, press Ctrl+C to stop and exit", "count", len(manager.List()))
 
-	//// After all attempts, check if any tunnels are active.
-	mu.Lock() // Lock to safely read len(allActiveStopFuncs)
-	activeTunnelCount := len(allActiveStopFuncs)
-	mu.Unlock()
+	// Set up channel to listen for OS interrupt signals.
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	if activeTunnelCount == 0 {
-		log.Println("🚫 No tunnels were successfully established. Exiting.")
-		return // Or os.Exit(1) if it should be an error state
+	// Render a live status table until we're asked to shut down.
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+	renderStatus(manager)
+
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			renderStatus(manager)
+		case <-signalChan:
+			break loop
+		}
 	}
 
-	// print summary of active tunnels
-	fmt.Printf("-----------------------------------------------------\n")
-	fmt.Printf("📊 Tunnel Summary:\n")
-	for siteName, site := range internal.Cfg.Sites {
-		for serviceName, service := range site.Services {
-			fmt.Printf("  - Site: %s, Service: %s (localhost:%d -> %s:%d)\n",
-				siteName, serviceName, service.LocalPort, service.Endpoint, service.DefaultPort)
+	slog.Info("received interrupt signal, shutting down active tunnels")
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(context.Background()); err != nil {
+			slog.Warn("admin API server did not shut down cleanly", "error", err)
 		}
 	}
-	fmt.Printf("-----------------------------------------------------\n")
-	log.Printf("🎉 %d tunnel(s) are now active. Press Ctrl+C to stop and exit.", activeTunnelCount)
 
-	// Set up channel to listen for OS interrupt signals.
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(context.Background()); err != nil {
+			slog.Warn("metrics server did not shut down cleanly", "error", err)
+		}
+	}
 
-	// Block until a signal is received.
-	<-signalChan
+	manager.StopAll()
 
-	log.Println("\n🚦 Received interrupt signal. Shutting down active tunnels...")
+	slog.Info("all active tunnels have been shut down, exiting")
+}
 
-	// Call all collected stop functions.
-	mu.Lock() // Lock for safe iteration, though no new appends should happen now.
-	for i, stopFunc := range allActiveStopFuncs {
-		if stopFunc != nil {
-			log.Printf("🔌 Stopping tunnel %d/%d...", i+1, activeTunnelCount)
-			stopFunc()
+// renderStatus prints the current state of every tunnel the manager is supervising.
+func renderStatus(manager *internal.TunnelManager) {
+	tunnels := manager.List()
+	sort.Slice(tunnels, func(i, j int) bool {
+		if tunnels[i].Site != tunnels[j].Site {
+			return tunnels[i].Site < tunnels[j].Site
 		}
-	}
-	mu.Unlock()
+		return tunnels[i].Service < tunnels[j].Service
+	})
 
-	log.Println("✅ All active tunnels have been shut down. Exiting.")
+	fmt.Printf("-----------------------------------------------------\n")
+	fmt.Printf("📊 Tunnel Status:\n")
+	for _, t := range tunnels {
+		fmt.Printf("  - [%s] Site: %s, Service: %s (localhost:%d -> %s:%d) -> %s\n",
+			t.ID, t.Site, t.Service, t.LocalPort, t.Endpoint, t.DefaultPort, t.State)
+	}
+	fmt.Printf("-----------------------------------------------------\n")
 }
 
 var rootCmd = &cobra.Command{
@@ -152,7 +184,13 @@ Prerequisites:
 Specify sites to connect to as arguments. If no sites are given, it attempts
 to connect to all sites defined in the configuration.
 Use the --services flag to filter which services to forward for the specified sites.
-If --services is not provided, all services for the selected sites will be forwarded.`,
+If --services is not provided, all services for the selected sites will be forwarded.
+
+Pass --admin-addr to also serve a local REST/JSON API (GET/POST /tunnels,
+DELETE /tunnels/{id}, POST /tunnels/{id}/restart, GET /healthz) for managing tunnels at runtime.
+
+Pass --metrics-addr to serve a Prometheus /metrics endpoint with tunnel up/down state,
+bytes transferred, reconnect counts and forward errors.`,
 	Run: run,
 }
 
@@ -169,6 +207,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "./config/sites.yaml", "Path to config file (e.g., ./config/sites.yaml)")
 	// Flags are local to this command.
 	rootCmd.Flags().StringSlice("services", []string{}, "Comma-separated list of service names to forward (e.g., mysql,redis). If empty, all services for a site are forwarded.")
+	rootCmd.Flags().StringVar(&adminAddr, "admin-addr", "", "Address (e.g. 127.0.0.1:7999) to serve the local tunnel admin API on. If empty, the admin API is disabled.")
+	rootCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Address (e.g. 127.0.0.1:9090) to serve the Prometheus /metrics endpoint on. If empty, metrics are disabled.")
 
 	// cobra.OnInitialize ensures loadConfig is called after flags are parsed
 	// but before the command's Run function is executed.
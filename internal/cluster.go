@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
@@ -22,6 +26,12 @@ import (
 	"k8s.io/client-go/transport/spdy"
 )
 
+// socatSidecarImage is the pinned image used for the ephemeral socat container
+// attached to proxy pods that need to reach an off-cluster target. Pinning it (rather
+// than relying on whatever package manager the proxy pod happens to have) is what lets
+// kubetunnel work against locked-down/distroless pods.
+const socatSidecarImage = "alpine/socat:1.7.4.4"
+
 var (
 	kubeConfig = filepath.Join(os.Getenv("HOME"), ".kube", "config")
 	clientMap  = make(map[string]*kubernetes.Clientset)
@@ -132,17 +142,145 @@ func ExecPodCommand(kubectx, namespace, podName string, command []string) (strin
 	return ExecToPod(client, config, podName, namespace, command)
 }
 
-// PortForward 创建到 pod 的端口转发连接
-func PortForward(kubectx, namespace, podName string, localPort, remotePort int) (func(), error) {
+// AttachSocatSidecar 通过 Kubernetes API 给 podName 挂载一个临时容器
+// (ephemeral container)，在 Pod 的网络命名空间里用 socat 监听 port 并转发到
+// targetAddr:port。相比 exec 进 Pod 装 socat，这个方式不依赖 Pod 里有包管理器或
+// 出网权限，也不会在 Pod 文件系统里留下脚本。返回临时容器的名字，供调用方在隧道
+// 停止时传给 RemoveEphemeralContainer。如果同名的临时容器已经存在（例如上一次
+// 运行留下的），会先核对它的 Command 是否还指向同一个 targetAddr：一致才复用，
+// 否则说明两个服务撞了同一个 port 但目标不同（或者 Endpoint 在 Pod 存活期间变
+// 了），直接报错而不是悄悄把流量转发到错误的后端。
+func AttachSocatSidecar(kubectx, namespace, podName string, port int, targetAddr string) (string, error) {
 	client, err := GetClient(kubectx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get client: %w", err)
+		return "", fmt.Errorf("failed to get client: %w", err)
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s: %w", podName, err)
+	}
+
+	containerName := fmt.Sprintf("kubetunnel-socat-%d", port)
+	command := []string{"socat", fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", port), fmt.Sprintf("TCP:%s:%d", targetAddr, port)}
+	for _, c := range pod.Spec.EphemeralContainers {
+		if c.Name == containerName {
+			if !reflect.DeepEqual(c.Command, command) {
+				return "", fmt.Errorf("socat sidecar %s in pod %s already forwards port %d to a different target (existing command %v), refusing to reuse it for target %s", containerName, podName, port, c.Command, targetAddr)
+			}
+			slog.Info("reusing existing socat sidecar", "container", containerName, "pod", podName)
+			return containerName, nil
+		}
+	}
+
+	podCopy := pod.DeepCopy()
+	podCopy.Spec.EphemeralContainers = append(podCopy.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    containerName,
+			Image:   socatSidecarImage,
+			Command: command,
+		},
+	})
+
+	if _, err := client.CoreV1().Pods(namespace).UpdateEphemeralContainers(context.Background(), podName, podCopy, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to attach socat ephemeral container to pod %s: %w", podName, err)
+	}
+
+	if err := waitForEphemeralContainerRunning(client, namespace, podName, containerName, 30*time.Second); err != nil {
+		return "", err
+	}
+
+	return containerName, nil
+}
+
+// waitForEphemeralContainerRunning 轮询 Pod 状态，直到名为 containerName 的临时
+// 容器进入 Running，或者超时。
+func waitForEphemeralContainerRunning(client *kubernetes.Clientset, namespace, podName, containerName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		pod, err := client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get pod %s: %w", podName, err)
+		}
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name == containerName {
+				if status.State.Running != nil {
+					return nil
+				}
+				if status.State.Terminated != nil {
+					return fmt.Errorf("socat sidecar %s in pod %s terminated: %s", containerName, podName, status.State.Terminated.Reason)
+				}
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for ephemeral container %s to become ready in pod %s", containerName, podName)
+}
+
+// RemoveEphemeralContainer 尽力清理隧道停止后的临时容器。Kubernetes API 目前不
+// 支持单独删除一个 ephemeral container（它会和 Pod 共存直到 Pod 被重建），所以这
+// 里只是记录一条日志，让用户知道它还会空跑一段时间。
+func RemoveEphemeralContainer(kubectx, namespace, podName, containerName string) error {
+	slog.Info("ephemeral container will remain idle until the pod is recreated",
+		"reason", "Kubernetes does not support removing a single ephemeral container",
+		"container", containerName, "namespace", namespace, "pod", podName)
+	return nil
+}
+
+// resolveLocalPort 决定 PortForward 实际应该绑定的本地端口：localPort 为 0 意味着
+// "挑一个空闲的临时端口"（写法参照 kubectl 的 PortForwardOptions 解析 ":remote"
+// 语法的方式），用 net.Listen 抢占一个端口、读出系统分配的端口号后立刻释放；
+// 非 0 的 localPort 会先探测一下是否已被占用，占用了就直接报错，而不是让调用方
+// 在更底层、更难看懂的 SPDY 错误里猜。
+func resolveLocalPort(localPort int) (int, error) {
+	if localPort == 0 {
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			return 0, fmt.Errorf("failed to allocate an ephemeral local port: %w", err)
+		}
+		defer listener.Close()
+		return listener.Addr().(*net.TCPAddr).Port, nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", localPort))
+	if err != nil {
+		return 0, fmt.Errorf("local port %d is already in use: %w", localPort, err)
+	}
+	listener.Close()
+	return localPort, nil
+}
+
+// PortForward 创建到 pod 的端口转发连接。localPort 为 0 时会自动分配一个空闲端口
+// （见 resolveLocalPort），实际绑定的端口通过 boundLocalPort 返回。除了停止函数
+// 外，还返回一个 done channel，当 ForwardPorts 因为连接断开、Pod 被删除等原因退出
+// 时会被关闭——调用方（例如 Supervise 的监控循环）可以监听它来判断隧道是否意外
+// 掉线，而不只是依赖 Pod Watch。
+//
+// client-go 的 portforwarder 自己管理本地监听，不对外暴露底层连接，所以这里让它
+// 监听一个内部的临时端口，再由我们自己的监听器顶在 boundLocalPort 上，逐字节
+// 转发——这样才能在 kubetunnel_bytes_transferred_total 里按 site/service/方向计数。
+// site、service 仅用于打点指标和日志，不参与转发逻辑。
+func PortForward(kubectx, namespace, podName string, localPort, remotePort int, site, service string) (stop func(), done <-chan struct{}, boundLocalPort int, err error) {
+	setupStart := time.Now()
+
+	client, err := GetClient(kubectx)
+	if err != nil {
+		forwardErrorsTotal.WithLabelValues(site, service, "get_client").Inc()
+		return nil, nil, 0, fmt.Errorf("failed to get client: %w", err)
 	}
 
 	config, err := getKubeRestConfig(kubectx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config: %w", err)
+		forwardErrorsTotal.WithLabelValues(site, service, "get_config").Inc()
+		return nil, nil, 0, fmt.Errorf("failed to get config: %w", err)
+	}
+
+	boundLocalPort, err = resolveLocalPort(localPort)
+	if err != nil {
+		forwardErrorsTotal.WithLabelValues(site, service, "local_port").Inc()
+		return nil, nil, 0, err
 	}
+
 	// 创建端口转发请求
 	req := client.CoreV1().RESTClient().Post().
 		Resource("pods").
@@ -152,11 +290,13 @@ func PortForward(kubectx, namespace, podName string, localPort, remotePort int)
 
 	transport, upgrader, err := spdy.RoundTripperFor(config)
 	if err != nil {
-		return nil, err
+		forwardErrorsTotal.WithLabelValues(site, service, "spdy_transport").Inc()
+		return nil, nil, 0, err
 	}
 
 	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
-	ports := []string{fmt.Sprintf("%d:%d", localPort, remotePort)}
+	// 本地端口传 0，让 portforwarder 自己挑一个内部端口；我们不直接把它暴露给用户。
+	ports := []string{fmt.Sprintf("0:%d", remotePort)}
 	readyChannel := make(chan struct{}, 1)
 	stopChannel := make(chan struct{}, 1)
 
@@ -168,23 +308,89 @@ func PortForward(kubectx, namespace, podName string, localPort, remotePort int)
 		os.Stdout,
 		os.Stderr)
 	if err != nil {
-		return nil, err
+		forwardErrorsTotal.WithLabelValues(site, service, "create_forwarder").Inc()
+		return nil, nil, 0, err
 	}
 
+	doneChannel := make(chan struct{})
+
 	// 在后台启动端口转发
 	go func() {
+		defer close(doneChannel)
 		err := fw.ForwardPorts()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Port forwarding failed: %v\n", err)
+			forwardErrorsTotal.WithLabelValues(site, service, "forward_ports").Inc()
+			slog.Error("port forwarding failed", "site", site, "service", service, "error", err)
 		}
 	}()
 
 	// 等待就绪
 	select {
 	case <-readyChannel:
-		return func() { close(stopChannel) }, nil
+		forwardedPorts, err := fw.GetPorts()
+		if err != nil || len(forwardedPorts) == 0 {
+			close(stopChannel)
+			forwardErrorsTotal.WithLabelValues(site, service, "get_ports").Inc()
+			return nil, nil, 0, fmt.Errorf("failed to read forwarded port: %w", err)
+		}
+		internalPort := int(forwardedPorts[0].Local)
+
+		listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", boundLocalPort))
+		if err != nil {
+			close(stopChannel)
+			forwardErrorsTotal.WithLabelValues(site, service, "listen").Inc()
+			return nil, nil, 0, fmt.Errorf("failed to listen on localhost:%d: %w", boundLocalPort, err)
+		}
+
+		go serveCountingProxy(listener, internalPort, site, service)
+
+		forwardSetupSeconds.WithLabelValues(site, service).Observe(time.Since(setupStart).Seconds())
+		stop := func() {
+			listener.Close()
+			close(stopChannel)
+		}
+		return stop, doneChannel, boundLocalPort, nil
 	case <-time.After(10 * time.Second):
 		close(stopChannel)
-		return nil, fmt.Errorf("timed out waiting for port forward to be ready")
+		forwardErrorsTotal.WithLabelValues(site, service, "ready_timeout").Inc()
+		return nil, nil, 0, fmt.Errorf("timed out waiting for port forward to be ready")
+	}
+}
+
+// serveCountingProxy 接受 boundLocalPort 上的连接，把每条连接转发到 internalPort
+// （client-go portforwarder 真正监听的地方），并按方向把字节数计入
+// kubetunnel_bytes_transferred_total。listener 被 Close 时自然退出。
+func serveCountingProxy(listener net.Listener, internalPort int, site, service string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return // 监听器已经被 stop() 关闭
+		}
+		go proxyConn(conn, internalPort, site, service)
+	}
+}
+
+func proxyConn(clientConn net.Conn, internalPort int, site, service string) {
+	defer clientConn.Close()
+
+	upstream, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", internalPort))
+	if err != nil {
+		slog.Warn("failed to dial local port-forward listener", "site", site, "service", service, "error", err)
+		return
 	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		n, _ := io.Copy(upstream, clientConn)
+		bytesTransferred.WithLabelValues(site, service, "in").Add(float64(n))
+	}()
+	go func() {
+		defer wg.Done()
+		n, _ := io.Copy(clientConn, upstream)
+		bytesTransferred.WithLabelValues(site, service, "out").Add(float64(n))
+	}()
+	wg.Wait()
 }
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolveLocalPortEphemeral(t *testing.T) {
+	port, err := resolveLocalPort(0)
+	if err != nil {
+		t.Fatalf("resolveLocalPort(0) returned error: %v", err)
+	}
+	if port == 0 {
+		t.Fatal("resolveLocalPort(0) returned port 0, expected a real ephemeral port")
+	}
+}
+
+func TestResolveLocalPortFixed(t *testing.T) {
+	// Port 0 above is freed before resolveLocalPort returns, so a second call with
+	// a fixed port should succeed cleanly.
+	port, err := resolveLocalPort(0)
+	if err != nil {
+		t.Fatalf("resolveLocalPort(0) returned error: %v", err)
+	}
+
+	got, err := resolveLocalPort(port)
+	if err != nil {
+		t.Fatalf("resolveLocalPort(%d) returned error: %v", port, err)
+	}
+	if got != port {
+		t.Fatalf("resolveLocalPort(%d) = %d, want %d", port, got, port)
+	}
+}
+
+func TestResolveLocalPortCollision(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to bind a port to simulate a collision: %v", err)
+	}
+	defer listener.Close()
+
+	busyPort := listener.Addr().(*net.TCPAddr).Port
+	if _, err := resolveLocalPort(busyPort); err == nil {
+		t.Fatalf("resolveLocalPort(%d) should have failed, port is already in use", busyPort)
+	}
+}
@@ -1,22 +1,33 @@
 package internal
 
 import (
-	"log"
+	"log/slog"
+	"os"
 
 	"github.com/spf13/viper"
 )
 
 type Service struct {
-	DefaultPort int    `mapstructure:"defaultPort"`
-	LocalPort   int    `mapstructure:"localPort"`
-	Endpoint    string `mapstructure:"endpoint"`
+	DefaultPort int `mapstructure:"defaultPort"`
+	// LocalPort is the local port to forward to. 0 means "pick a free ephemeral
+	// port"; the actually-bound port is reported back via Handle.BoundLocalPort
+	// and the admin API / tunnel status output rather than this field.
+	LocalPort int    `mapstructure:"localPort"`
+	Endpoint  string `mapstructure:"endpoint"`
+	// Proxy is a resource reference in kubectl's TYPE/NAME form (svc/mydb,
+	// deploy/proxy, sts/redis-proxy, pod/xxx) identifying the proxy pod to tunnel
+	// through for this service. If empty, the site's legacy Porxy label selector
+	// is used instead.
+	Proxy string `mapstructure:"proxy"`
 }
 
 type Site struct {
-	KubeContext string             `mapstructure:"kubeContext"`
-	Porxy       string             `mapstructure:"proxy"`
-	Namespace   string             `mapstructure:"namespace"`
-	Services    map[string]Service `mapstructure:"services"`
+	KubeContext string `mapstructure:"kubeContext"`
+	// Porxy is the legacy label selector used to find a proxy pod directly,
+	// applied to any service that does not set its own Proxy resource reference.
+	Porxy     string             `mapstructure:"proxy"`
+	Namespace string             `mapstructure:"namespace"`
+	Services  map[string]Service `mapstructure:"services"`
 }
 
 type Config struct {
@@ -28,9 +39,11 @@ var Cfg Config
 func Init(configPath string) {
 	viper.SetConfigFile(configPath)
 	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %v", err)
+		slog.Error("error reading config file", "error", err)
+		os.Exit(1)
 	}
 	if err := viper.Unmarshal(&Cfg); err != nil {
-		log.Fatalf("Error parsing config: %v", err)
+		slog.Error("error parsing config", "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ResolvePod 把一个形如 "svc/mydb"、"deploy/proxy"、"sts/redis-proxy" 或 "pod/xxx"
+// 的代理引用（写法参照 kubectl 的 TYPE/NAME 语法）解析成一个具体的、处于 Ready
+// 状态可以转发流量的 Pod 名字：
+//   - svc/NAME：按 Service 的选择器列出 Pod，挑一个 Ready 的作为端点；
+//   - deploy/NAME、sts/NAME：按对应工作负载的选择器列出 Pod，挑一个 Ready 的；
+//   - pod/NAME：直接使用该 Pod，不做 Ready 校验（调用方显式点名的 Pod）。
+func ResolvePod(kubectx, namespace, proxyRef string) (string, error) {
+	kind, name, err := parseResourceRef(proxyRef)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := GetClient(kubectx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get client: %w", err)
+	}
+
+	switch kind {
+	case "pod", "po":
+		return name, nil
+
+	case "svc", "service":
+		svc, err := client.CoreV1().Services(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get service %s/%s: %w", namespace, name, err)
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return "", fmt.Errorf("service %s/%s has no selector, cannot resolve a backing pod", namespace, name)
+		}
+		return resolveReadyPod(client, namespace, labels.SelectorFromSet(svc.Spec.Selector).String(), proxyRef)
+
+	case "deploy", "deployment":
+		deploy, err := client.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid selector on deployment %s/%s: %w", namespace, name, err)
+		}
+		return resolveReadyPod(client, namespace, selector.String(), proxyRef)
+
+	case "sts", "statefulset":
+		sts, err := client.AppsV1().StatefulSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get statefulset %s/%s: %w", namespace, name, err)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid selector on statefulset %s/%s: %w", namespace, name, err)
+		}
+		return resolveReadyPod(client, namespace, selector.String(), proxyRef)
+
+	default:
+		return "", fmt.Errorf("unsupported resource type %q in proxy reference %q (expected pod, svc, deploy or sts)", kind, proxyRef)
+	}
+}
+
+// parseResourceRef 把 "TYPE/NAME" 拆成两段，格式不对就直接报错。
+func parseResourceRef(ref string) (kind, name string, err error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid proxy reference %q, expected TYPE/NAME (e.g. svc/mydb, deploy/proxy, sts/redis-proxy, pod/xxx)", ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+// resolveReadyPod 按 labelSelector 列出 namespace 下的 Pod，返回第一个处于 Ready
+// 状态的。describedBy 只用于错误信息，方便用户定位是哪条 proxy 配置解析失败的。
+func resolveReadyPod(client *kubernetes.Clientset, namespace, labelSelector, describedBy string) (string, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pods for %q: %w", describedBy, err)
+	}
+	for _, pod := range pods.Items {
+		if isPodReady(&pod) {
+			return pod.Name, nil
+		}
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for %q (selector %q) in namespace %s", describedBy, labelSelector, namespace)
+	}
+	return "", fmt.Errorf("no ready pods found for %q (selector %q) in namespace %s", describedBy, labelSelector, namespace)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestParseResourceRef(t *testing.T) {
+	cases := []struct {
+		ref      string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{ref: "svc/mydb", wantKind: "svc", wantName: "mydb"},
+		{ref: "deploy/proxy", wantKind: "deploy", wantName: "proxy"},
+		{ref: "sts/redis-proxy", wantKind: "sts", wantName: "redis-proxy"},
+		{ref: "pod/xxx", wantKind: "pod", wantName: "xxx"},
+		{ref: "no-slash", wantErr: true},
+		{ref: "svc/", wantErr: true},
+		{ref: "/mydb", wantErr: true},
+	}
+
+	for _, c := range cases {
+		kind, name, err := parseResourceRef(c.ref)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseResourceRef(%q): expected error, got kind=%q name=%q", c.ref, kind, name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseResourceRef(%q): unexpected error: %v", c.ref, err)
+			continue
+		}
+		if kind != c.wantKind || name != c.wantName {
+			t.Errorf("parseResourceRef(%q) = (%q, %q), want (%q, %q)", c.ref, kind, name, c.wantKind, c.wantName)
+		}
+	}
+}
+
+func TestResolvePodUnsupportedKind(t *testing.T) {
+	// Register a client for the context so ResolvePod gets past GetClient and
+	// reaches the resource-type check; no request is sent for an unsupported type.
+	const kubectx = "test-unsupported-kind"
+	mutex.Lock()
+	clientMap[kubectx] = kubernetes.NewForConfigOrDie(&rest.Config{Host: "http://127.0.0.1:1"})
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		delete(clientMap, kubectx)
+		mutex.Unlock()
+	})
+
+	_, err := ResolvePod(kubectx, "ns", "cronjob/backup")
+	if err == nil {
+		t.Fatal("expected error for unsupported resource type, got nil")
+	}
+	if !strings.Contains(err.Error(), "unsupported resource type") {
+		t.Fatalf("expected an unsupported resource type error, got: %v", err)
+	}
+}
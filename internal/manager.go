@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TunnelID identifies one tunnel managed by a TunnelManager.
+type TunnelID string
+
+// TunnelInfo is a point-in-time snapshot of one managed tunnel, used by both the
+// admin HTTP API and cmd/run's status rendering.
+type TunnelInfo struct {
+	ID          TunnelID
+	Site        string
+	Service     string
+	LocalPort   int
+	Endpoint    string
+	DefaultPort int
+	State       TunnelState
+	// BytesIn/BytesOut are not filled in by List and always read 0; per-tunnel
+	// byte counts are exported only through kubetunnel_bytes_transferred_total.
+	BytesIn  int64
+	BytesOut int64
+}
+
+type managedTunnel struct {
+	site    string
+	service string
+	handle  *Handle
+}
+
+// TunnelManager owns the set of tunnels started for a run: it is the single place
+// that starts, lists, restarts and stops them, so the same instance can back both
+// the admin HTTP API and the Ctrl+C shutdown path in cmd/run.
+type TunnelManager struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	next    int
+	tunnels map[TunnelID]*managedTunnel
+}
+
+// NewTunnelManager creates a TunnelManager whose tunnels are all derived from ctx;
+// cancelling ctx stops every tunnel the manager has started.
+func NewTunnelManager(ctx context.Context) *TunnelManager {
+	return &TunnelManager{ctx: ctx, tunnels: make(map[TunnelID]*managedTunnel)}
+}
+
+// Start begins supervising a new tunnel for site/service and returns its ID.
+func (m *TunnelManager) Start(site, service string) (TunnelID, error) {
+	handle, err := Supervise(m.ctx, site, service)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	id := TunnelID(fmt.Sprintf("t-%d", m.next))
+	m.tunnels[id] = &managedTunnel{site: site, service: service, handle: handle}
+	return id, nil
+}
+
+// Stop stops and forgets the tunnel with the given ID.
+func (m *TunnelManager) Stop(id TunnelID) error {
+	m.mu.Lock()
+	t, ok := m.tunnels[id]
+	if ok {
+		delete(m.tunnels, id)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("tunnel %s not found", id)
+	}
+	t.handle.Stop()
+	return nil
+}
+
+// Restart stops the current tunnel behind id and starts a fresh one for the same
+// site/service, keeping the same ID.
+func (m *TunnelManager) Restart(id TunnelID) error {
+	m.mu.Lock()
+	t, ok := m.tunnels[id]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tunnel %s not found", id)
+	}
+
+	t.handle.Stop()
+	handle, err := Supervise(m.ctx, t.site, t.service)
+	if err != nil {
+		return fmt.Errorf("failed to restart tunnel %s: %w", id, err)
+	}
+
+	m.mu.Lock()
+	// A concurrent Stop(id) may have removed id from m.tunnels while we were
+	// stopping the old handle and starting the new one; in that case t is
+	// orphaned and we must stop the handle we just started instead of
+	// writing it onto a struct nobody can reach anymore.
+	if current, ok := m.tunnels[id]; !ok || current != t {
+		m.mu.Unlock()
+		handle.Stop()
+		return fmt.Errorf("tunnel %s was removed during restart", id)
+	}
+	t.handle = handle
+	m.mu.Unlock()
+	return nil
+}
+
+// List returns a snapshot of every tunnel the manager currently knows about.
+func (m *TunnelManager) List() []TunnelInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]TunnelInfo, 0, len(m.tunnels))
+	for id, t := range m.tunnels {
+		svc := Cfg.Sites[t.site].Services[t.service]
+		localPort := svc.LocalPort
+		// LocalPort 为 0 表示配置里要求自动分配，实际绑定的端口要从 Handle 读，
+		// 在隧道第一次建立之前 BoundLocalPort 仍然是 0。
+		if bound := t.handle.BoundLocalPort(); bound != 0 {
+			localPort = bound
+		}
+		infos = append(infos, TunnelInfo{
+			ID:          id,
+			Site:        t.site,
+			Service:     t.service,
+			LocalPort:   localPort,
+			Endpoint:    svc.Endpoint,
+			DefaultPort: svc.DefaultPort,
+			State:       t.handle.Status(),
+		})
+	}
+	return infos
+}
+
+// StopAll stops every tunnel currently managed by m.
+func (m *TunnelManager) StopAll() {
+	m.mu.Lock()
+	ids := make([]TunnelID, 0, len(m.tunnels))
+	for id := range m.tunnels {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		_ = m.Stop(id)
+	}
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus 指标。全部用 site/service 打标，方便按站点或服务聚合；forwardErrorsTotal
+// 额外带一个 reason 维度，定位是哪个阶段失败的。
+var (
+	tunnelUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubetunnel_tunnel_up",
+		Help: "Whether a tunnel is currently Ready (1) or not (0).",
+	}, []string{"site", "service"})
+
+	bytesTransferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubetunnel_bytes_transferred_total",
+		Help: "Total bytes proxied through a tunnel, by direction (in = local->pod, out = pod->local).",
+	}, []string{"site", "service", "direction"})
+
+	reconnectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubetunnel_reconnects_total",
+		Help: "Total number of times a tunnel had to be re-established after losing connectivity.",
+	}, []string{"site", "service"})
+
+	forwardErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubetunnel_forward_errors_total",
+		Help: "Total number of errors encountered while setting up or running a tunnel, by reason.",
+	}, []string{"site", "service", "reason"})
+
+	forwardSetupSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubetunnel_forward_setup_seconds",
+		Help:    "Time taken to establish a port-forward, from dialing the API server to it becoming ready.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"site", "service"})
+)
+
+func init() {
+	prometheus.MustRegister(tunnelUp, bytesTransferred, reconnectsTotal, forwardErrorsTotal, forwardSetupSeconds)
+}
+
+// MetricsHandler 返回一个可以直接挂到 /metrics 上的 http.Handler。
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
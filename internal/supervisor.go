@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// TunnelState 描述一个受 Supervisor 管理的隧道当前所处的状态。
+type TunnelState string
+
+const (
+	StateConnecting   TunnelState = "Connecting"
+	StateReady        TunnelState = "Ready"
+	StateReconnecting TunnelState = "Reconnecting"
+	StateFailed       TunnelState = "Failed"
+)
+
+const (
+	reconnectInitialBackoff = 1 * time.Second
+	reconnectMaxBackoff     = 30 * time.Second
+)
+
+// Handle 是 Supervise 返回的隧道句柄：可以查询当前状态、订阅状态变化，或者停止
+// 隧道并等待监督循环退出。
+type Handle struct {
+	Site    string
+	Service string
+
+	mu             sync.Mutex
+	state          TunnelState
+	boundLocalPort int
+
+	states chan TunnelState
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Status 返回隧道当前状态。
+func (h *Handle) Status() TunnelState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// BoundLocalPort 返回隧道实际绑定的本地端口。配置的 LocalPort 为 0（即交给系统
+// 分配）时，这是发现实际端口的方式；连接建立之前返回 0。
+func (h *Handle) BoundLocalPort() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.boundLocalPort
+}
+
+// States 返回一个只读 channel，隧道每次状态变化都会往里面发一条。channel 有缓冲，
+// 调用方不消费也不会让监督循环阻塞。
+func (h *Handle) States() <-chan TunnelState {
+	return h.states
+}
+
+// Stop 终止隧道的监督循环、关闭当前活跃的端口转发，并阻塞到循环真正退出。
+func (h *Handle) Stop() {
+	h.cancel()
+	<-h.done
+}
+
+func (h *Handle) setState(s TunnelState) {
+	h.mu.Lock()
+	h.state = s
+	h.mu.Unlock()
+	select {
+	case h.states <- s:
+	default:
+	}
+}
+
+// Supervise 为 site/service 启动一个后台监督循环，持续维持隧道存活：参照 kubelet
+// SyncLoop 的思路，通过对目标 Pod 的 Watch 驱动状态机——Pod 进入 NotReady、被
+// 删除，或者端口转发自身异常退出，都会触发重新解析 Pod（这样 Deployment 滚动发布
+// 产生的新 Pod 也能被捡起来）并以指数退避重新建立端口转发。
+func Supervise(ctx context.Context, site, service string) (*Handle, error) {
+	siteConfig, ok := Cfg.Sites[site]
+	if !ok {
+		return nil, fmt.Errorf("site %s not found in configuration", site)
+	}
+	serviceConfig, ok := siteConfig.Services[service]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found in site %s configuration", service, site)
+	}
+	if err := InitClients([]string{siteConfig.KubeContext}); err != nil {
+		return nil, fmt.Errorf("failed to initialize clients for context %s: %w", siteConfig.KubeContext, err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	h := &Handle{
+		Site:    site,
+		Service: service,
+		state:   StateConnecting,
+		states:  make(chan TunnelState, 8),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go h.run(runCtx, siteConfig, serviceConfig)
+	return h, nil
+}
+
+func (h *Handle) run(ctx context.Context, siteConfig Site, serviceConfig Service) {
+	defer close(h.done)
+	defer tunnelUp.WithLabelValues(h.Site, h.Service).Set(0)
+	backoff := reconnectInitialBackoff
+	firstAttempt := true
+
+	for ctx.Err() == nil {
+		h.setState(StateConnecting)
+		stopTunnel, podName, boundLocalPort, forwardDone, err := h.connect(siteConfig, serviceConfig)
+		if err != nil {
+			slog.Error("failed to establish tunnel", "site", h.Site, "service", h.Service, "error", err)
+			h.setState(StateReconnecting)
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		if !firstAttempt {
+			reconnectsTotal.WithLabelValues(h.Site, h.Service).Inc()
+		}
+		firstAttempt = false
+
+		h.mu.Lock()
+		h.boundLocalPort = boundLocalPort
+		h.mu.Unlock()
+
+		// 把第一次分配到的端口钉住，后续重连复用同一个本地端口，而不是在
+		// localPort: 0 时每次都重新挑一个——否则 IDE/工具指向的端口会在 Pod
+		// 重启后失效，违背了"只发现一次端口"的初衷。
+		if serviceConfig.LocalPort == 0 {
+			serviceConfig.LocalPort = boundLocalPort
+		}
+
+		backoff = reconnectInitialBackoff
+		h.setState(StateReady)
+		tunnelUp.WithLabelValues(h.Site, h.Service).Set(1)
+		slog.Info("tunnel ready", "site", h.Site, "service", h.Service, "pod", podName, "localPort", boundLocalPort)
+
+		reason := h.waitUntilUnhealthy(ctx, siteConfig.KubeContext, siteConfig.Namespace, podName, forwardDone)
+		stopTunnel()
+		tunnelUp.WithLabelValues(h.Site, h.Service).Set(0)
+
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Info("tunnel lost, reconnecting", "site", h.Site, "service", h.Service, "reason", reason)
+		h.setState(StateReconnecting)
+	}
+}
+
+// connect 解析一个可用的代理 Pod 并建立一次隧道，返回停止函数、Pod 名字、实际绑定
+// 的本地端口，以及一个在底层端口转发异常退出时会被关闭的 channel。
+func (h *Handle) connect(siteConfig Site, serviceConfig Service) (func(), string, int, <-chan struct{}, error) {
+	return startTunnel(h.Site, siteConfig, h.Service, serviceConfig)
+}
+
+// waitUntilUnhealthy 阻塞到以下情况之一发生：ctx 被取消、底层端口转发退出，或者
+// 对 podName 的 Watch 观察到它被删除/变为 NotReady。返回触发退出的原因，用于日志。
+func (h *Handle) waitUntilUnhealthy(ctx context.Context, kubectx, namespace, podName string, forwardDone <-chan struct{}) string {
+	client, err := GetClient(kubectx)
+	if err != nil {
+		// 拿不到 client 基本不会发生（连接阶段已经用过），降级为只看 forwardDone。
+		select {
+		case <-ctx.Done():
+			return "shutting down"
+		case <-forwardDone:
+			return "port-forward exited"
+		}
+	}
+
+	watcher, err := client.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("metadata.name=%s", podName),
+	})
+	if err != nil {
+		slog.Warn("failed to watch pod, falling back to forward-only monitoring", "site", h.Site, "service", h.Service, "pod", podName, "error", err)
+		select {
+		case <-ctx.Done():
+			return "shutting down"
+		case <-forwardDone:
+			return "port-forward exited"
+		}
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "shutting down"
+		case <-forwardDone:
+			return "port-forward exited"
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return "pod watch channel closed"
+			}
+			switch event.Type {
+			case watch.Deleted:
+				return fmt.Sprintf("pod %s deleted", podName)
+			case watch.Modified, watch.Added:
+				pod, ok := event.Object.(*corev1.Pod)
+				if ok && !isPodReady(pod) {
+					return fmt.Sprintf("pod %s became not-ready", podName)
+				}
+			case watch.Error:
+				return "pod watch error"
+			}
+		}
+	}
+}
+
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func nextBackoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > reconnectMaxBackoff {
+		return reconnectMaxBackoff
+	}
+	return next
+}
@@ -0,0 +1,29 @@
+package internal
+
+import "testing"
+
+func TestNextBackoffDoublesUpToMax(t *testing.T) {
+	backoff := reconnectInitialBackoff
+	for i := 0; i < 10; i++ {
+		next := nextBackoff(backoff)
+		if next > reconnectMaxBackoff {
+			t.Fatalf("nextBackoff(%v) = %v, exceeds max backoff %v", backoff, next, reconnectMaxBackoff)
+		}
+		if next < backoff {
+			t.Fatalf("nextBackoff(%v) = %v, expected it to grow or stay capped", backoff, next)
+		}
+		backoff = next
+	}
+	if backoff != reconnectMaxBackoff {
+		t.Fatalf("backoff did not converge to reconnectMaxBackoff after repeated doubling: got %v", backoff)
+	}
+}
+
+func TestNextBackoffCapsAtMax(t *testing.T) {
+	if got := nextBackoff(reconnectMaxBackoff); got != reconnectMaxBackoff {
+		t.Fatalf("nextBackoff(%v) = %v, want it to stay capped at %v", reconnectMaxBackoff, got, reconnectMaxBackoff)
+	}
+	if got := nextBackoff(reconnectMaxBackoff * 10); got != reconnectMaxBackoff {
+		t.Fatalf("nextBackoff(%v) = %v, want %v", reconnectMaxBackoff*10, got, reconnectMaxBackoff)
+	}
+}